@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CommandController holds the runtime-mutable settings operators can change
+// on the fly via MQTT commands, and the channels the main loop watches to
+// act on them.
+type CommandController struct {
+	mu   sync.Mutex
+	unit string
+
+	TriggerRead chan struct{}
+	SetInterval chan time.Duration
+	ResetDedup  chan struct{}
+}
+
+func NewCommandController(initialUnit string) *CommandController {
+	return &CommandController{
+		unit:        initialUnit,
+		TriggerRead: make(chan struct{}, 1),
+		SetInterval: make(chan time.Duration, 1),
+		ResetDedup:  make(chan struct{}, 1),
+	}
+}
+
+// Unit returns the currently configured reporting unit ("C", "F", or "both").
+func (cc *CommandController) Unit() string {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.unit
+}
+
+// Handle processes one command (the final segment of the cmnd/# topic) with
+// its payload, returning the text to acknowledge it with on stat/result.
+func (cc *CommandController) Handle(command, payload string) (string, error) {
+	switch command {
+	case "read":
+		notify(cc.TriggerRead)
+		return "read triggered", nil
+
+	case "interval":
+		seconds, err := strconv.Atoi(strings.TrimSpace(payload))
+		if err != nil || seconds <= 0 {
+			return "", fmt.Errorf("invalid interval %q", payload)
+		}
+		interval := time.Duration(seconds) * time.Second
+		select {
+		case cc.SetInterval <- interval:
+		default:
+		}
+		return fmt.Sprintf("interval set to %ds", seconds), nil
+
+	case "unit":
+		unit := strings.ToUpper(strings.TrimSpace(payload))
+		switch unit {
+		case "C", "F", "BOTH":
+			cc.mu.Lock()
+			cc.unit = unit
+			cc.mu.Unlock()
+			return fmt.Sprintf("unit set to %s", unit), nil
+		default:
+			return "", fmt.Errorf("invalid unit %q, want C, F, or both", payload)
+		}
+
+	case "reset":
+		notify(cc.ResetDedup)
+		return "dedup state reset", nil
+
+	default:
+		return "", fmt.Errorf("unknown command %q", command)
+	}
+}
+
+// notify sends on a buffered signal channel without blocking if a signal is
+// already pending.
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
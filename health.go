@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics holds the Prometheus collectors exported on /metrics.
+type Metrics struct {
+	Temperature    *prometheus.GaugeVec
+	ReadErrors     prometheus.Counter
+	PublishErrors  prometheus.Counter
+	MQTTReconnects prometheus.Counter
+}
+
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		Temperature: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "ds18b20_temperature_celsius",
+			Help: "Last smoothed temperature reading, in Celsius, per sensor.",
+		}, []string{"sensor_id"}),
+		ReadErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "ds18b20_read_errors_total",
+			Help: "Total number of failed sensor reads.",
+		}),
+		PublishErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "ds18b20_publish_errors_total",
+			Help: "Total number of failed sink publishes.",
+		}),
+		MQTTReconnects: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "mqtt_reconnects_total",
+			Help: "Total number of MQTT reconnects after the initial connection.",
+		}),
+	}
+
+	prometheus.MustRegister(m.Temperature, m.ReadErrors, m.PublishErrors, m.MQTTReconnects)
+	return m
+}
+
+// HealthState is the shared, mutex-protected state behind /healthz and
+// /readyz: whether the MQTT client is currently connected, and when a
+// sensor was last read successfully.
+type HealthState struct {
+	mu            sync.Mutex
+	mqttConnected bool
+	lastReadAt    time.Time
+}
+
+func NewHealthState() *HealthState {
+	return &HealthState{}
+}
+
+func (hs *HealthState) SetMQTTConnected(connected bool) {
+	hs.mu.Lock()
+	hs.mqttConnected = connected
+	hs.mu.Unlock()
+}
+
+func (hs *HealthState) RecordRead() {
+	hs.mu.Lock()
+	hs.lastReadAt = time.Now()
+	hs.mu.Unlock()
+}
+
+func (hs *HealthState) snapshot() (bool, time.Time) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	return hs.mqttConnected, hs.lastReadAt
+}
+
+func (hs *HealthState) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	connected, lastRead := hs.snapshot()
+
+	body := struct {
+		MQTTConnected        bool    `json:"mqtt_connected"`
+		SinceLastReadSeconds float64 `json:"since_last_read_seconds,omitempty"`
+	}{
+		MQTTConnected: connected,
+	}
+	if !lastRead.IsZero() {
+		body.SinceLastReadSeconds = time.Since(lastRead).Seconds()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("Error encoding healthz response: %v", err)
+	}
+}
+
+func (hs *HealthState) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	connected, _ := hs.snapshot()
+	if !connected {
+		http.Error(w, "mqtt not connected", http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok"))
+}
+
+// startHTTPServer starts the /healthz, /readyz, and /metrics endpoints in
+// the background and returns the server so the caller can shut it down.
+func startHTTPServer(addr string, health *HealthState) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", health.handleHealthz)
+	mux.HandleFunc("/readyz", health.handleReadyz)
+	mux.Handle("/metrics", promhttp.Handler())
+
+	server := &http.Server{Addr: addr, Handler: mux}
+
+	go func() {
+		log.Printf("Starting HTTP server on %s", addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("HTTP server error: %v", err)
+		}
+	}()
+
+	return server
+}
@@ -1,15 +1,26 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
 	"fmt"
 	"log"
+	"math"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"text/template"
 	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
@@ -20,33 +31,73 @@ type Config struct {
 	MQTTTopic    string
 	SensorID     string
 	ReadInterval time.Duration
+	ReadTimeout  time.Duration
+
+	TopicTemplate    string
+	SensorConfigPath string
+
+	HADiscoveryEnabled bool
+	HADiscoveryPrefix  string
+	HANodeID           string
+
+	Outputs []string
+
+	InfluxURL           string
+	InfluxToken         string
+	InfluxOrg           string
+	InfluxBucket        string
+	InfluxFlushInterval time.Duration
+
+	Unit string
+
+	Deadband          float64
+	HeartbeatInterval time.Duration
+	SmoothingAlpha    float64
+
+	HTTPAddr string
+
+	MQTTVersion            string
+	MQTTTLSEnabled         bool
+	MQTTCACertPath         string
+	MQTTClientCertPath     string
+	MQTTClientKeyPath      string
+	MQTTInsecureSkipVerify bool
+	PersistenceDir         string
+	MQTTPublishQoS         byte
 }
 
 type TemperatureSensor struct {
 	devicePath string
+	ID         string
 }
 
-var PrevTemp = 0
-
-func NewTemperatureSensor() (*TemperatureSensor, error) {
-	// Find DS18B20 sensor
-	devices, err := filepath.Glob("/sys/bus/w1/devices/28-*")
-	if err != nil {
-		return nil, fmt.Errorf("error searching for DS18B20 devices: %v", err)
+func NewTemperatureSensor(devicePath string) *TemperatureSensor {
+	return &TemperatureSensor{
+		devicePath: filepath.Join(devicePath, "w1_slave"),
+		ID:         filepath.Base(devicePath),
 	}
+}
 
-	if len(devices) == 0 {
-		return nil, fmt.Errorf("no DS18B20 sensors found")
+// ReadTemperatureContext reads the sensor, aborting with ctx.Err() if the
+// read takes longer than the context allows.
+func (ts *TemperatureSensor) ReadTemperatureContext(ctx context.Context) (float64, error) {
+	type result struct {
+		temp float64
+		err  error
 	}
 
-	// Use the first device found
-	devicePath := filepath.Join(devices[0], "w1_slave")
-
-	log.Printf("Found DS18B20 sensor: %s", devices[0])
-
-	return &TemperatureSensor{
-		devicePath: devicePath,
-	}, nil
+	ch := make(chan result, 1)
+	go func() {
+		temp, err := ts.ReadTemperature()
+		ch <- result{temp, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case r := <-ch:
+		return r.temp, r.err
+	}
 }
 
 func (ts *TemperatureSensor) ReadTemperature() (float64, error) {
@@ -83,29 +134,318 @@ func (ts *TemperatureSensor) ReadTemperature() (float64, error) {
 	return temperature, nil
 }
 
+// SensorConfig holds per-sensor overrides loaded from the sensor config file.
+type SensorConfig struct {
+	Name   string  `yaml:"name"`
+	Offset float64 `yaml:"offset"`
+}
+
+// sensorConfigFile mirrors the on-disk YAML layout, keyed by 1-Wire ID.
+type sensorConfigFile struct {
+	Sensors map[string]SensorConfig `yaml:"sensors"`
+}
+
+func loadSensorConfig(path string) (map[string]SensorConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading sensor config file: %v", err)
+	}
+
+	var parsed sensorConfigFile
+	if err := yaml.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("error parsing sensor config file: %v", err)
+	}
+
+	return parsed.Sensors, nil
+}
+
+// sensorState tracks the per-sensor smoothing and change-detection baseline
+// that would otherwise require a package-level global.
+type sensorState struct {
+	smoothed    float64
+	hasSmoothed bool
+
+	lastPublished float64
+	lastPublishAt time.Time
+	hasPublished  bool
+}
+
+// SensorBus enumerates every DS18B20 on the 1-Wire bus and tracks the
+// smoothing and change-detection state for each one so callers can dedup
+// per sensor.
+type SensorBus struct {
+	Sensors       []*TemperatureSensor
+	sensorConfig  map[string]SensorConfig
+	topicTemplate *template.Template
+
+	deadband          float64
+	heartbeatInterval time.Duration
+	smoothingAlpha    float64
+
+	mu     sync.Mutex
+	states map[string]*sensorState
+}
+
+func NewSensorBus(config Config) (*SensorBus, error) {
+	devices, err := filepath.Glob("/sys/bus/w1/devices/28-*")
+	if err != nil {
+		return nil, fmt.Errorf("error searching for DS18B20 devices: %v", err)
+	}
+
+	if len(devices) == 0 {
+		return nil, fmt.Errorf("no DS18B20 sensors found")
+	}
+
+	topicTemplate, err := template.New("topic").Parse(config.TopicTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing topic template: %v", err)
+	}
+
+	sensorConfig := map[string]SensorConfig{}
+	if config.SensorConfigPath != "" {
+		sensorConfig, err = loadSensorConfig(config.SensorConfigPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sensors := make([]*TemperatureSensor, 0, len(devices))
+	for _, device := range devices {
+		sensor := NewTemperatureSensor(device)
+		if name := sensorConfig[sensor.ID].Name; name != "" {
+			log.Printf("Found DS18B20 sensor: %s (%s)", device, name)
+		} else {
+			log.Printf("Found DS18B20 sensor: %s", device)
+		}
+		sensors = append(sensors, sensor)
+	}
+
+	return &SensorBus{
+		Sensors:           sensors,
+		sensorConfig:      sensorConfig,
+		topicTemplate:     topicTemplate,
+		deadband:          config.Deadband,
+		heartbeatInterval: config.HeartbeatInterval,
+		smoothingAlpha:    config.SmoothingAlpha,
+		states:            map[string]*sensorState{},
+	}, nil
+}
+
+// Topic renders the per-sensor publish topic for the given 1-Wire ID.
+func (sb *SensorBus) Topic(id string) (string, error) {
+	var buf bytes.Buffer
+	if err := sb.topicTemplate.Execute(&buf, struct{ ID string }{ID: id}); err != nil {
+		return "", fmt.Errorf("error rendering topic template: %v", err)
+	}
+	return buf.String(), nil
+}
+
+// Calibrate applies the configured calibration offset, if any, for the sensor.
+func (sb *SensorBus) Calibrate(id string, temperature float64) float64 {
+	return temperature + sb.sensorConfig[id].Offset
+}
+
+// FriendlyName returns the operator-assigned name for id from the sensor
+// config file, or id itself if none was configured.
+func (sb *SensorBus) FriendlyName(id string) string {
+	if name := sb.sensorConfig[id].Name; name != "" {
+		return name
+	}
+	return id
+}
+
+// Smooth applies an exponential moving average to raw, seeded with the first
+// reading seen for id, and returns the smoothed value.
+func (sb *SensorBus) Smooth(id string, raw float64) float64 {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	state := sb.stateFor(id)
+	if !state.hasSmoothed {
+		state.smoothed = raw
+		state.hasSmoothed = true
+	} else {
+		state.smoothed = sb.smoothingAlpha*raw + (1-sb.smoothingAlpha)*state.smoothed
+	}
+
+	return state.smoothed
+}
+
+// ShouldPublish reports whether smoothed has moved at least Deadband away
+// from the last published value, or whether HeartbeatInterval has elapsed
+// since the last publish, and records the decision as the new baseline.
+// Passing force skips all of that and always publishes, for commanded
+// on-demand reads where the operator wants the current reading regardless
+// of dedup state.
+func (sb *SensorBus) ShouldPublish(id string, smoothed float64, force bool) bool {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	state := sb.stateFor(id)
+	now := time.Now()
+
+	if force || !state.hasPublished || math.Abs(smoothed-state.lastPublished) >= sb.deadband ||
+		now.Sub(state.lastPublishAt) >= sb.heartbeatInterval {
+		state.hasPublished = true
+		state.lastPublished = smoothed
+		state.lastPublishAt = now
+		return true
+	}
+
+	return false
+}
+
+// ResetDedup clears the recorded baseline for every sensor, so the next
+// reading always publishes regardless of how little it changed.
+func (sb *SensorBus) ResetDedup() {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	sb.states = map[string]*sensorState{}
+}
+
+// stateFor returns the sensorState for id, creating it if necessary. Callers
+// must hold sb.mu.
+func (sb *SensorBus) stateFor(id string) *sensorState {
+	state, ok := sb.states[id]
+	if !ok {
+		state = &sensorState{}
+		sb.states[id] = state
+	}
+	return state
+}
+
 type MQTTClient struct {
-	client mqtt.Client
-	topic  string
+	client            mqtt.Client
+	availabilityTopic string
+	config            Config
+}
+
+// DiscoverySensor identifies a sensor for Home Assistant MQTT Discovery:
+// its 1-Wire ID, the state topic it publishes readings to, and the
+// operator-assigned friendly name to display in Home Assistant.
+type DiscoverySensor struct {
+	ID    string
+	Topic string
+	Name  string
+}
+
+// haDevice describes the physical device in a Home Assistant discovery payload.
+type haDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+	Name         string   `json:"name"`
+}
+
+// haDiscoveryPayload is the config payload published to
+// homeassistant/sensor/<node>/<sensor>/config for MQTT discovery.
+type haDiscoveryPayload struct {
+	Name                string   `json:"name"`
+	UniqueID            string   `json:"unique_id"`
+	StateTopic          string   `json:"state_topic"`
+	AvailabilityTopic   string   `json:"availability_topic"`
+	PayloadAvailable    string   `json:"payload_available"`
+	PayloadNotAvailable string   `json:"payload_not_available"`
+	DeviceClass         string   `json:"device_class"`
+	StateClass          string   `json:"state_class"`
+	UnitOfMeasurement   string   `json:"unit_of_measurement"`
+	ValueTemplate       string   `json:"value_template"`
+	Device              haDevice `json:"device"`
 }
 
-func NewMQTTClient(config Config) (*MQTTClient, error) {
+func NewMQTTClient(config Config, sensors []DiscoverySensor, commands *CommandController, health *HealthState, metrics *Metrics) (*MQTTClient, error) {
+	availabilityTopic := fmt.Sprintf("%s/availability", config.MQTTTopic)
+	cmndBase := fmt.Sprintf("%s/cmnd/", config.MQTTTopic)
+	cmndFilter := cmndBase + "#"
+	statTopic := fmt.Sprintf("%s/stat/result", config.MQTTTopic)
+
+	scheme := "tcp"
+	if config.MQTTTLSEnabled {
+		scheme = "ssl"
+	}
+	brokerURL := fmt.Sprintf("%s://%s:%d", scheme, config.MQTTBroker, config.MQTTPort)
+
 	opts := mqtt.NewClientOptions()
-	brokerURL := fmt.Sprintf("tcp://%s:%d", config.MQTTBroker, config.MQTTPort)
 	opts.AddBroker(brokerURL)
 	opts.SetClientID("ds18b20-sensor")
 	opts.SetUsername(config.MQTTUsername)
 	opts.SetPassword(config.MQTTPassword)
 	opts.SetAutoReconnect(true)
-	opts.SetCleanSession(true)
+	opts.SetWill(availabilityTopic, "offline", 1, true)
+
+	if config.MQTTTLSEnabled {
+		tlsConfig, err := newTLSConfig(config)
+		if err != nil {
+			return nil, fmt.Errorf("error building TLS config: %v", err)
+		}
+		opts.SetTLSConfig(tlsConfig)
+	}
+
+	if config.MQTTVersion == "5" {
+		opts.SetProtocolVersion(5)
+	} else {
+		opts.SetProtocolVersion(4)
+	}
+
+	if config.PersistenceDir != "" {
+		opts.SetStore(mqtt.NewFileStore(config.PersistenceDir))
+		opts.SetCleanSession(false)
+	} else {
+		opts.SetCleanSession(true)
+	}
 
 	// Connection lost handler
 	opts.SetConnectionLostHandler(func(client mqtt.Client, err error) {
 		log.Printf("MQTT connection lost: %v", err)
+		health.SetMQTTConnected(false)
 	})
 
+	mc := &MQTTClient{
+		availabilityTopic: availabilityTopic,
+		config:            config,
+	}
+
+	connectedBefore := false
+
 	// On connect handler
 	opts.SetOnConnectHandler(func(client mqtt.Client) {
 		log.Println("Connected to MQTT broker")
+		health.SetMQTTConnected(true)
+		if connectedBefore {
+			metrics.MQTTReconnects.Inc()
+		}
+		connectedBefore = true
+
+		if token := client.Publish(availabilityTopic, 1, true, "online"); token.Wait() && token.Error() != nil {
+			log.Printf("Error publishing availability: %v", token.Error())
+		}
+
+		if config.HADiscoveryEnabled {
+			for _, sensor := range sensors {
+				if err := mc.publishDiscovery(sensor); err != nil {
+					log.Printf("Error publishing Home Assistant discovery config: %v", err)
+				}
+			}
+		}
+
+		// Subscribe to commands on every (re)connect, mirroring the Tasmota
+		// cmnd/<command> -> stat/result convention.
+		commandHandler := func(client mqtt.Client, msg mqtt.Message) {
+			command := strings.TrimPrefix(msg.Topic(), cmndBase)
+			result, err := commands.Handle(command, string(msg.Payload()))
+			if err != nil {
+				result = fmt.Sprintf("error: %v", err)
+				log.Printf("Error handling command %q: %v", command, err)
+			}
+
+			if token := client.Publish(statTopic, 1, false, result); token.Wait() && token.Error() != nil {
+				log.Printf("Error publishing command result: %v", token.Error())
+			}
+		}
+
+		if token := client.Subscribe(cmndFilter, 1, commandHandler); token.Wait() && token.Error() != nil {
+			log.Printf("Error subscribing to command topic %s: %v", cmndFilter, token.Error())
+		}
 	})
 
 	client := mqtt.NewClient(opts)
@@ -114,18 +454,98 @@ func NewMQTTClient(config Config) (*MQTTClient, error) {
 		return nil, fmt.Errorf("failed to connect to MQTT broker: %v", token.Error())
 	}
 
-	return &MQTTClient{
-		client: client,
-		topic:  config.MQTTTopic,
-	}, nil
+	mc.client = client
+
+	return mc, nil
+}
+
+// newTLSConfig builds the TLS configuration for the MQTT connection from
+// config, optionally trusting a custom CA and presenting a client
+// certificate for mutual TLS.
+func newTLSConfig(config Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.MQTTInsecureSkipVerify}
+
+	if config.MQTTCACertPath != "" {
+		caCert, err := os.ReadFile(config.MQTTCACertPath)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA cert: %v", err)
+		}
+		caPool := x509.NewCertPool()
+		if !caPool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no valid certificates found in %s", config.MQTTCACertPath)
+		}
+		tlsConfig.RootCAs = caPool
+	}
+
+	if config.MQTTClientCertPath != "" || config.MQTTClientKeyPath != "" {
+		cert, err := tls.LoadX509KeyPair(config.MQTTClientCertPath, config.MQTTClientKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// publishDiscovery publishes a retained Home Assistant MQTT Discovery config
+// message describing sensor as a temperature entity.
+func (mc *MQTTClient) publishDiscovery(sensor DiscoverySensor) error {
+	uniqueID := fmt.Sprintf("%s_%s", mc.config.HANodeID, sensor.ID)
+	discoveryTopic := fmt.Sprintf("%s/sensor/%s/%s/config", mc.config.HADiscoveryPrefix, mc.config.HANodeID, sensor.ID)
+
+	payload := haDiscoveryPayload{
+		Name:                fmt.Sprintf("%s Temperature", sensor.Name),
+		UniqueID:            uniqueID,
+		StateTopic:          sensor.Topic,
+		AvailabilityTopic:   mc.availabilityTopic,
+		PayloadAvailable:    "online",
+		PayloadNotAvailable: "offline",
+		DeviceClass:         "temperature",
+		StateClass:          "measurement",
+		UnitOfMeasurement:   "°C",
+		ValueTemplate:       "{{ value_json.temperature }}",
+		Device: haDevice{
+			Identifiers:  []string{sensor.ID},
+			Manufacturer: "Maxim Integrated",
+			Model:        "DS18B20",
+			Name:         sensor.Name,
+		},
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error marshalling discovery payload: %v", err)
+	}
+
+	token := mc.client.Publish(discoveryTopic, 1, true, body)
+	token.Wait()
+
+	if token.Error() != nil {
+		return fmt.Errorf("failed to publish discovery config: %v", token.Error())
+	}
+
+	log.Printf("Published Home Assistant discovery config to %s", discoveryTopic)
+	return nil
 }
 
-func (mc *MQTTClient) PublishTemperature(temperature float64) error {
+// PublishTemperature publishes a reading as JSON. temperature and raw are
+// always Celsius and stay that way regardless of unit: the Home Assistant
+// discovery config (see publishDiscovery) hardcodes value_json.temperature
+// as the Celsius state, published once at connect time, so that field can't
+// be allowed to change shape under the "unit" command. unit instead only
+// picks which of fahrenheit/celsius a human consumer should prefer to
+// display.
+func (mc *MQTTClient) PublishTemperature(topic string, temperature, raw float64, unit string) error {
 	faren := (temperature * 1.8) + 32
-	payload := fmt.Sprintf(`{"temperature": %.2f, "fahrenheit": %.2fF, "unit": "C", "timestamp": "%s"}`,
-		temperature, faren, time.Now().Format(time.RFC3339))
 
-	token := mc.client.Publish(mc.topic, 0, false, payload)
+	payload := fmt.Sprintf(`{"temperature": %.2f, "raw": %.2f, "fahrenheit": %.2f, "unit": "%s", "timestamp": "%s"}`,
+		temperature, raw, faren, unit, time.Now().Format(time.RFC3339))
+
+	// Published at MQTTPublishQoS (default 1) rather than QoS 0 so readings
+	// queue in the persistent session store (see PersistenceDir) and are
+	// delivered once connectivity returns, instead of being dropped.
+	token := mc.client.Publish(topic, mc.config.MQTTPublishQoS, false, payload)
 	token.Wait()
 
 	if token.Error() != nil {
@@ -137,6 +557,9 @@ func (mc *MQTTClient) PublishTemperature(temperature float64) error {
 }
 
 func (mc *MQTTClient) Disconnect() {
+	if token := mc.client.Publish(mc.availabilityTopic, 1, true, "offline"); token.Wait() && token.Error() != nil {
+		log.Printf("Error publishing offline availability: %v", token.Error())
+	}
 	mc.client.Disconnect(250)
 }
 
@@ -148,11 +571,60 @@ func loadConfig() Config {
 		MQTTPassword: getEnvOrDefault("MQTT_PASSWORD", ""),
 		MQTTTopic:    getEnvOrDefault("MQTT_TOPIC", "sensors/temperature"),
 		ReadInterval: time.Duration(getEnvIntOrDefault("READ_INTERVAL_SECONDS", 30)) * time.Second,
+		ReadTimeout:  time.Duration(getEnvIntOrDefault("READ_TIMEOUT_SECONDS", 5)) * time.Second,
+
+		TopicTemplate:    getEnvOrDefault("TOPIC_TEMPLATE", "sensors/temperature/{{.ID}}"),
+		SensorConfigPath: getEnvOrDefault("SENSOR_CONFIG_FILE", ""),
+
+		HADiscoveryEnabled: getEnvBoolOrDefault("HA_DISCOVERY_ENABLED", false),
+		HADiscoveryPrefix:  getEnvOrDefault("HA_DISCOVERY_PREFIX", "homeassistant"),
+		HANodeID:           getEnvOrDefault("HA_NODE_ID", "ds18b20"),
+
+		Outputs: getEnvListOrDefault("OUTPUTS", []string{"mqtt"}),
+
+		InfluxURL:           getEnvOrDefault("INFLUX_URL", "http://localhost:8086"),
+		InfluxToken:         getEnvOrDefault("INFLUX_TOKEN", ""),
+		InfluxOrg:           getEnvOrDefault("INFLUX_ORG", ""),
+		InfluxBucket:        getEnvOrDefault("INFLUX_BUCKET", ""),
+		InfluxFlushInterval: time.Duration(getEnvIntOrDefault("INFLUX_FLUSH_INTERVAL_SECONDS", 10)) * time.Second,
+
+		Unit: strings.ToUpper(getEnvOrDefault("UNIT", "both")),
+
+		Deadband:          getEnvFloatOrDefault("DEADBAND_C", 0.2),
+		HeartbeatInterval: time.Duration(getEnvIntOrDefault("HEARTBEAT_INTERVAL_SECONDS", 300)) * time.Second,
+		SmoothingAlpha:    getEnvFloatOrDefault("SMOOTHING_ALPHA", 0.3),
+
+		HTTPAddr: getEnvOrDefault("HTTP_ADDR", ":8080"),
+
+		MQTTVersion:            getEnvOrDefault("MQTT_VERSION", "3.1.1"),
+		MQTTTLSEnabled:         getEnvBoolOrDefault("MQTT_TLS_ENABLED", false),
+		MQTTCACertPath:         getEnvOrDefault("MQTT_CA_CERT", ""),
+		MQTTClientCertPath:     getEnvOrDefault("MQTT_CLIENT_CERT", ""),
+		MQTTClientKeyPath:      getEnvOrDefault("MQTT_CLIENT_KEY", ""),
+		MQTTInsecureSkipVerify: getEnvBoolOrDefault("MQTT_INSECURE_SKIP_VERIFY", false),
+		PersistenceDir:         getEnvOrDefault("PERSISTENCE_DIR", ""),
+		MQTTPublishQoS:         byte(getEnvIntOrDefault("MQTT_PUBLISH_QOS", 1)),
 	}
 
 	return config
 }
 
+func getEnvListOrDefault(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	parts := strings.Split(value, ",")
+	list := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if trimmed := strings.TrimSpace(part); trimmed != "" {
+			list = append(list, trimmed)
+		}
+	}
+	return list
+}
+
 func getEnvOrDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -169,52 +641,189 @@ func getEnvIntOrDefault(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvBoolOrDefault(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+func getEnvFloatOrDefault(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+func containsString(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
+
 func main() {
 	log.Println("Starting DS18B20 Temperature Monitor")
 
 	// Load configuration
 	config := loadConfig()
 
-	// Initialize temperature sensor
-	sensor, err := NewTemperatureSensor()
+	// Discover every DS18B20 on the bus
+	bus, err := NewSensorBus(config)
 	if err != nil {
-		log.Fatalf("Failed to initialize temperature sensor: %v", err)
+		log.Fatalf("Failed to initialize sensor bus: %v", err)
+	}
+
+	discoverySensors := make([]DiscoverySensor, 0, len(bus.Sensors))
+	for _, sensor := range bus.Sensors {
+		topic, err := bus.Topic(sensor.ID)
+		if err != nil {
+			log.Fatalf("Failed to render topic for sensor %s: %v", sensor.ID, err)
+		}
+		discoverySensors = append(discoverySensors, DiscoverySensor{ID: sensor.ID, Topic: topic, Name: bus.FriendlyName(sensor.ID)})
 	}
 
-	// Initialize MQTT client
-	mqttClient, err := NewMQTTClient(config)
+	health := NewHealthState()
+	metrics := NewMetrics()
+	httpServer := startHTTPServer(config.HTTPAddr, health)
+	defer func() {
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer shutdownCancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Error shutting down HTTP server: %v", err)
+		}
+	}()
+
+	// The MQTT connection doubles as the control plane (availability, Home
+	// Assistant discovery, command subscription), so it's established
+	// whenever "mqtt" is an output sink or discovery is enabled - but an
+	// influx- or stdout-only deployment should be able to run with no
+	// broker reachable at all.
+	commands := NewCommandController(config.Unit)
+
+	var mqttClient *MQTTClient
+	if containsString(config.Outputs, "mqtt") || config.HADiscoveryEnabled {
+		mqttClient, err = NewMQTTClient(config, discoverySensors, commands, health, metrics)
+		if err != nil {
+			log.Fatalf("Failed to initialize MQTT client: %v", err)
+		}
+		defer mqttClient.Disconnect()
+	} else {
+		// No broker in play, so there's nothing for /readyz to wait on.
+		health.SetMQTTConnected(true)
+	}
+
+	publishers, err := newPublishers(config, mqttClient)
 	if err != nil {
-		log.Fatalf("Failed to initialize MQTT client: %v", err)
+		log.Fatalf("Failed to initialize output sinks: %v", err)
 	}
-	defer mqttClient.Disconnect()
+	defer closePublishers(publishers)
+
+	log.Printf("Reading %d sensor(s) every %v, publishing to: %v", len(bus.Sensors), config.ReadInterval, config.Outputs)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	log.Printf("Reading temperature every %v", config.ReadInterval)
-	log.Printf("Publishing to topic: %s", config.MQTTTopic)
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-sigCh
+		log.Printf("Received signal %v, shutting down", sig)
+		cancel()
+	}()
 
 	// Main loop
 	ticker := time.NewTicker(config.ReadInterval)
 	defer ticker.Stop()
 
+	readAll := func(force bool) {
+		var wg sync.WaitGroup
+
+		for _, sensor := range bus.Sensors {
+			wg.Add(1)
+			go func(sensor *TemperatureSensor) {
+				defer wg.Done()
+
+				readCtx, readCancel := context.WithTimeout(ctx, config.ReadTimeout)
+				defer readCancel()
+
+				raw, err := sensor.ReadTemperatureContext(readCtx)
+				if err != nil {
+					log.Printf("Error reading sensor %s: %v", sensor.ID, err)
+					metrics.ReadErrors.Inc()
+					return
+				}
+				health.RecordRead()
+				raw = bus.Calibrate(sensor.ID, raw)
+				smoothed := bus.Smooth(sensor.ID, raw)
+				metrics.Temperature.WithLabelValues(sensor.ID).Set(smoothed)
+
+				if !bus.ShouldPublish(sensor.ID, smoothed, force) {
+					return
+				}
+
+				topic, err := bus.Topic(sensor.ID)
+				if err != nil {
+					log.Printf("Error rendering topic for sensor %s: %v", sensor.ID, err)
+					return
+				}
+
+				reading := Reading{
+					SensorID:   sensor.ID,
+					Topic:      topic,
+					Celsius:    smoothed,
+					RawCelsius: raw,
+					Fahrenheit: (smoothed * 1.8) + 32,
+					Unit:       commands.Unit(),
+					Timestamp:  time.Now(),
+				}
+
+				var sinkWG sync.WaitGroup
+				for _, publisher := range publishers {
+					sinkWG.Add(1)
+					go func(publisher Publisher) {
+						defer sinkWG.Done()
+						if err := publisher.Publish(reading); err != nil {
+							log.Printf("Error publishing sensor %s to sink: %v", sensor.ID, err)
+							metrics.PublishErrors.Inc()
+						}
+					}(publisher)
+				}
+				sinkWG.Wait()
+			}(sensor)
+		}
+
+		wg.Wait()
+	}
+
 	for {
 		select {
+		case <-ctx.Done():
+			log.Println("Shutdown complete")
+			return
+
 		case <-ticker.C:
-			temperature, err := sensor.ReadTemperature()
-			if err != nil {
-				log.Printf("Error reading temperature: %v", err)
-				continue
-			}
-			faren := (temperature * 1.8) + 32
-			log.Printf("Published temperature: %.2f°C, fahrenheit: %.2fF", temperature, faren)
-			if int(temperature) == PrevTemp {
-				continue // Skip publishing if the temperature hasn't changed
-			} else {
-				PrevTemp = int(temperature)
-			}
+			readAll(false)
 
-			err = mqttClient.PublishTemperature(temperature)
-			if err != nil {
-				log.Printf("Error publishing temperature: %v", err)
-			}
+		case <-commands.TriggerRead:
+			// A commanded read means "publish the current reading now", so
+			// it bypasses the deadband/heartbeat dedup that ticker-driven
+			// reads go through.
+			readAll(true)
+
+		case interval := <-commands.SetInterval:
+			ticker.Reset(interval)
+			log.Printf("Read interval changed to %v", interval)
+
+		case <-commands.ResetDedup:
+			bus.ResetDedup()
+			log.Println("Dedup state reset")
 		}
 	}
 }
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api"
+)
+
+// Reading is a single sensor sample, enough for any sink to render its own
+// wire format from.
+type Reading struct {
+	SensorID   string
+	Topic      string
+	Celsius    float64
+	RawCelsius float64
+	Fahrenheit float64
+	Unit       string
+	Timestamp  time.Time
+}
+
+// Publisher delivers a Reading to one output sink.
+type Publisher interface {
+	Publish(reading Reading) error
+}
+
+// closablePublisher is implemented by sinks that hold resources (connections,
+// batching buffers) that need to be released on shutdown.
+type closablePublisher interface {
+	Close()
+}
+
+// newPublishers builds the set of sinks enabled by config.Outputs.
+func newPublishers(config Config, mqttClient *MQTTClient) ([]Publisher, error) {
+	publishers := make([]Publisher, 0, len(config.Outputs))
+
+	for _, output := range config.Outputs {
+		switch output {
+		case "mqtt":
+			publishers = append(publishers, &mqttPublisher{client: mqttClient})
+		case "influx":
+			influxPub, err := newInfluxPublisher(config)
+			if err != nil {
+				return nil, fmt.Errorf("error initializing InfluxDB sink: %v", err)
+			}
+			publishers = append(publishers, influxPub)
+		case "stdout":
+			publishers = append(publishers, stdoutPublisher{})
+		default:
+			return nil, fmt.Errorf("unknown output sink: %s", output)
+		}
+	}
+
+	return publishers, nil
+}
+
+// closePublishers releases any resources held by closable sinks.
+func closePublishers(publishers []Publisher) {
+	for _, publisher := range publishers {
+		if closable, ok := publisher.(closablePublisher); ok {
+			closable.Close()
+		}
+	}
+}
+
+// mqttPublisher adapts MQTTClient to the Publisher interface.
+type mqttPublisher struct {
+	client *MQTTClient
+}
+
+func (p *mqttPublisher) Publish(reading Reading) error {
+	return p.client.PublishTemperature(reading.Topic, reading.Celsius, reading.RawCelsius, reading.Unit)
+}
+
+// stdoutPublisher writes each reading as a line of NDJSON to stdout.
+type stdoutPublisher struct{}
+
+func (stdoutPublisher) Publish(reading Reading) error {
+	body, err := json.Marshal(struct {
+		SensorID   string  `json:"sensor_id"`
+		Celsius    float64 `json:"celsius"`
+		RawCelsius float64 `json:"raw_celsius"`
+		Fahrenheit float64 `json:"fahrenheit"`
+		Timestamp  string  `json:"timestamp"`
+	}{
+		SensorID:   reading.SensorID,
+		Celsius:    reading.Celsius,
+		RawCelsius: reading.RawCelsius,
+		Fahrenheit: reading.Fahrenheit,
+		Timestamp:  reading.Timestamp.Format(time.RFC3339),
+	})
+	if err != nil {
+		return fmt.Errorf("error marshalling reading: %v", err)
+	}
+
+	if _, err := fmt.Println(string(body)); err != nil {
+		return fmt.Errorf("error writing reading to stdout: %v", err)
+	}
+	return nil
+}
+
+// influxPublisher writes readings to InfluxDB v2 using the non-blocking,
+// batched write API, flushing on config.InfluxFlushInterval.
+type influxPublisher struct {
+	client   influxdb2.Client
+	writeAPI api.WriteAPI
+	host     string
+}
+
+func newInfluxPublisher(config Config) (*influxPublisher, error) {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+
+	opts := influxdb2.DefaultOptions().SetFlushInterval(uint(config.InfluxFlushInterval.Milliseconds()))
+	client := influxdb2.NewClientWithOptions(config.InfluxURL, config.InfluxToken, opts)
+	writeAPI := client.WriteAPI(config.InfluxOrg, config.InfluxBucket)
+
+	go func() {
+		for err := range writeAPI.Errors() {
+			log.Printf("Error writing to InfluxDB: %v", err)
+		}
+	}()
+
+	return &influxPublisher{client: client, writeAPI: writeAPI, host: host}, nil
+}
+
+func (p *influxPublisher) Publish(reading Reading) error {
+	point := influxdb2.NewPoint(
+		"temperature",
+		map[string]string{
+			"sensor_id": reading.SensorID,
+			"host":      p.host,
+		},
+		map[string]interface{}{
+			"celsius":     reading.Celsius,
+			"raw_celsius": reading.RawCelsius,
+			"fahrenheit":  reading.Fahrenheit,
+		},
+		reading.Timestamp,
+	)
+
+	p.writeAPI.WritePoint(point)
+	return nil
+}
+
+func (p *influxPublisher) Close() {
+	p.writeAPI.Flush()
+	p.client.Close()
+}
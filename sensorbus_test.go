@@ -0,0 +1,118 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func newTestBus(deadband float64, heartbeat time.Duration, alpha float64) *SensorBus {
+	return &SensorBus{
+		deadband:          deadband,
+		heartbeatInterval: heartbeat,
+		smoothingAlpha:    alpha,
+		states:            map[string]*sensorState{},
+	}
+}
+
+func TestSensorBusSmooth(t *testing.T) {
+	tests := []struct {
+		name  string
+		alpha float64
+		raws  []float64
+		want  float64
+	}{
+		{"first reading seeds the baseline unsmoothed", 0.3, []float64{21.5}, 21.5},
+		{"equal-weight EMA averages two readings", 0.5, []float64{20.0, 22.0}, 21.0},
+		{"low alpha favors the prior smoothed value", 0.1, []float64{0.0, 10.0}, 1.0},
+		{"high alpha favors the new raw reading", 0.9, []float64{0.0, 10.0}, 9.0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := newTestBus(0, 0, tt.alpha)
+			var got float64
+			for _, raw := range tt.raws {
+				got = bus.Smooth("28-000", raw)
+			}
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Smooth() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSensorBusShouldPublish(t *testing.T) {
+	type step struct {
+		smoothed float64
+		force    bool
+		sleep    time.Duration
+		want     bool
+	}
+
+	tests := []struct {
+		name      string
+		deadband  float64
+		heartbeat time.Duration
+		steps     []step
+	}{
+		{
+			name:      "first reading always publishes",
+			deadband:  0.5,
+			heartbeat: time.Hour,
+			steps: []step{
+				{smoothed: 20.0, want: true},
+			},
+		},
+		{
+			name:      "within deadband and before heartbeat does not publish",
+			deadband:  0.5,
+			heartbeat: time.Hour,
+			steps: []step{
+				{smoothed: 20.0, want: true},
+				{smoothed: 20.2, want: false},
+			},
+		},
+		{
+			name:      "move past the deadband publishes",
+			deadband:  0.5,
+			heartbeat: time.Hour,
+			steps: []step{
+				{smoothed: 20.0, want: true},
+				{smoothed: 20.6, want: true},
+			},
+		},
+		{
+			name:      "heartbeat elapsing publishes even within deadband",
+			deadband:  0.5,
+			heartbeat: 10 * time.Millisecond,
+			steps: []step{
+				{smoothed: 20.0, want: true},
+				{smoothed: 20.05, sleep: 20 * time.Millisecond, want: true},
+			},
+		},
+		{
+			name:      "force publishes regardless of deadband or heartbeat",
+			deadband:  0.5,
+			heartbeat: time.Hour,
+			steps: []step{
+				{smoothed: 20.0, want: true},
+				{smoothed: 20.01, force: true, want: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := newTestBus(tt.deadband, tt.heartbeat, 1)
+			for i, s := range tt.steps {
+				if s.sleep > 0 {
+					time.Sleep(s.sleep)
+				}
+				if got := bus.ShouldPublish("28-000", s.smoothed, s.force); got != s.want {
+					t.Errorf("step %d: ShouldPublish() = %v, want %v", i, got, s.want)
+				}
+			}
+		})
+	}
+}